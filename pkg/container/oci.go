@@ -1,18 +1,23 @@
 package container
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/capsule8/reactive8/pkg/inotify"
 	"github.com/capsule8/reactive8/pkg/stream"
 	"github.com/kelseyhightower/envconfig"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
 //
@@ -29,23 +34,216 @@ const (
 	ociRunning
 	ociStopped
 	ociDeleted
+
+	// ociExecStarted and ociExecExited describe a process started inside
+	// an already-running container via "runc exec"/"crun exec" (e.g.
+	// kubectl exec, docker exec, healthchecks), rather than the
+	// container's own init process.
+	ociExecStarted
+	ociExecExited
 )
 
 type ociEvent struct {
+	// Runtime is the name of the backend that produced this event
+	// (e.g. "docker", "containerd", "crio", "podman").
+	Runtime    string
 	ID         string
 	State      ociState
 	ConfigJSON string
+
+	// Spec is config.json unmarshalled as an OCI runtime spec. It is nil
+	// if the file couldn't be parsed (e.g. a runtime-specific dialect),
+	// in which case callers can still fall back to ConfigJSON.
+	Spec *specs.Spec
+
+	// Annotations, Args, Env, RootPath, Mounts, Namespaces,
+	// CgroupsPath and Hostname mirror the corresponding fields of Spec,
+	// surfaced directly so callers don't need to nil-check Spec for the
+	// fields they use most (CRI-O/Kubernetes annotations carry pod UID,
+	// sandbox ID, container name, image ref and log path).
+	Annotations map[string]string
+	Args        []string
+	Env         []string
+	RootPath    string
+	Mounts      []specs.Mount
+	Namespaces  []specs.LinuxNamespace
+	CgroupsPath string
+	Hostname    string
+
+	// ExitCode, OOMKilled and ErrorMessage describe why a Stopped
+	// container exited. They are populated from the runtime's exit file
+	// and the container's cgroup memory controller.
+	ExitCode     int32
+	OOMKilled    bool
+	ErrorMessage string
+
+	// SessionID, PID and Argv are populated for ociExecStarted/
+	// ociExecExited events, describing a process started inside the
+	// container separately from its init process. ExitCode is reused
+	// for ociExecExited.
+	SessionID string
+	PID       int32
+	Argv      []string
 }
 
+// ----------------------------------------------------------------------------
+// OCI runtime backends
+// ----------------------------------------------------------------------------
+
+// ociBackend describes the on-disk layout of an OCI-compliant container
+// runtime so the sensor can watch it alongside other runtimes and normalize
+// its on-disk artifacts into ociEvents.
+type ociBackend struct {
+	// Name identifies the backend in emitted ociEvents.
+	Name string
+
+	// Dirs are the state-directory roots this backend's containers live
+	// under. Most runtimes use a single root; CRI-O splits its state
+	// across a storage directory and a conmon runtime directory, each
+	// with its own shape.
+	Dirs []ociStateDir
+
+	// ExitDir is the shared directory conmon-based runtimes (CRI-O,
+	// podman) write per-container exit status files into, named by
+	// container ID (e.g. "<ExitDir>/<containerID>"), as opposed to
+	// runc's flat "exit" file inside the container's own directory.
+	// Empty for backends that don't use conmon.
+	ExitDir string
+}
+
+// ociStateDir is one state-directory root a backend's containers live
+// under.
+type ociStateDir struct {
+	// Path is the directory root to watch.
+	Path string
+
+	// NamespaceDepth is the number of directory levels beneath Path
+	// that precede the container-ID directory itself. Docker and
+	// CRI-O's conmon runtime directory place container directories
+	// directly under Path (depth 0); containerd nests them one level
+	// further under a per-namespace directory, and containers/storage
+	// (CRI-O's storage backend, and podman) one level further under a
+	// fixed "overlay-containers" directory (depth 1 for both).
+	NamespaceDepth int
+
+	// NamespaceName, if non-empty, restricts the NamespaceDepth levels
+	// beneath Path to a single directory of that exact name, skipping
+	// every other entry outright. containerd's namespace directories are
+	// arbitrary (e.g. "k8s.io", "moby") so it leaves this empty, but
+	// containers/storage (CRI-O's storage backend, and podman) always
+	// name it "overlay-containers"; without this, the other siblings it
+	// creates under the same root ("overlay", "overlay-layers",
+	// "mounts") would be walked as if they too held container
+	// directories.
+	NamespaceName string
+}
+
+// builtinOciBackends are the backends recognized out of the box.
+var builtinOciBackends = map[string]*ociBackend{
+	"docker": {
+		Name: "docker",
+		Dirs: []ociStateDir{{Path: "/var/run/docker/libcontainerd"}},
+	},
+	"containerd": {
+		Name: "containerd",
+		Dirs: []ociStateDir{{Path: "/run/containerd/runc", NamespaceDepth: 1}},
+	},
+	"crio": {
+		Name: "crio",
+		Dirs: []ociStateDir{
+			{Path: "/var/run/containers/storage", NamespaceDepth: 1, NamespaceName: "overlay-containers"},
+			{Path: "/var/run/crio"},
+		},
+		ExitDir: "/var/run/crio/exits",
+	},
+	"podman": {
+		Name: "podman",
+		Dirs: []ociStateDir{
+			{Path: "/var/lib/containers/storage", NamespaceDepth: 1, NamespaceName: "overlay-containers"},
+		},
+		ExitDir: "/var/run/libpod/exits",
+	},
+}
+
+// ociRuntimes is the set of backends enabled for this sensor, keyed by
+// name. It is seeded from builtinOciBackends and then overridden/extended
+// by ociConfig.
+var ociRuntimes map[string]*ociBackend
+
 // ----------------------------------------------------------------------------
 // OCI configuration file format
 // ----------------------------------------------------------------------------
 
 var ociConfig struct {
 	// OciContainerDir is the path to the directory used for the
-	// container runtime's container state directories
-	// (i.e. /var/run/docker/libcontainerd)
+	// docker/libcontainerd runtime's container state directories.
+	// Kept for backward compatibility; prefer Runtimes for other
+	// runtimes.
 	OciContainerDir string `split_words:"true" default:"/var/run/docker/libcontainerd"`
+
+	// Runtimes optionally overrides or adds backend state directories,
+	// formatted as "name:dir,name:dir" (e.g.
+	// "containerd:/run/containerd/runc,myruntime:/var/run/myruntime").
+	// Overriding a builtin backend's dir this way only replaces the path
+	// of its primary state directory (Dirs[0]); its NamespaceDepth,
+	// NamespaceName, any further Dirs and its ExitDir are kept as-is.
+	Runtimes string `split_words:"true"`
+
+	// ResyncInterval controls how often the sensor re-walks every
+	// backend's state directories to recover from inotify events missed
+	// under load (e.g. an IN_Q_OVERFLOW).
+	ResyncInterval time.Duration `split_words:"true" default:"5m"`
+}
+
+// buildOciRuntimes seeds the enabled backends from builtinOciBackends, then
+// applies containerDir (OCI_CONTAINER_DIR, overriding docker's state
+// directory) and runtimesEnv (OCI_RUNTIMES, overriding or adding backends)
+// on top.
+func buildOciRuntimes(containerDir, runtimesEnv string) map[string]*ociBackend {
+	runtimes := make(map[string]*ociBackend, len(builtinOciBackends))
+	for name, b := range builtinOciBackends {
+		cp := *b
+		runtimes[name] = &cp
+	}
+
+	runtimes["docker"].Dirs = []ociStateDir{{Path: containerDir}}
+
+	for _, pair := range strings.Split(runtimesEnv, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			log.Fatalf("oci: invalid OCI_RUNTIMES entry %q", pair)
+		}
+		name, dir := parts[0], parts[1]
+
+		if existing, ok := runtimes[name]; ok {
+			// A known backend (builtin or already added by an earlier
+			// entry): keep its NamespaceDepth/NamespaceName/ExitDir and
+			// any further Dirs, only repointing its primary state
+			// directory. Replacing the whole ociBackend here would
+			// silently turn containerd's NamespaceDepth back to 0 (a
+			// pre-existing namespace directory would then be mistaken
+			// for a container directory) and drop CRI-O/podman's
+			// NamespaceName and ExitDir, breaking namespace filtering
+			// and exit/OOM reporting.
+			dirs := append([]ociStateDir(nil), existing.Dirs...)
+			if len(dirs) == 0 {
+				dirs = []ociStateDir{{}}
+			}
+			dirs[0].Path = dir
+
+			updated := *existing
+			updated.Dirs = dirs
+			runtimes[name] = &updated
+			continue
+		}
+
+		runtimes[name] = &ociBackend{Name: name, Dirs: []ociStateDir{{Path: dir}}}
+	}
+
+	return runtimes
 }
 
 func init() {
@@ -53,18 +251,28 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if ociConfig.ResyncInterval <= 0 {
+		// time.NewTicker panics on a non-positive duration, and Go's
+		// duration parsing accepts a bare "0" for OCI_RESYNC_INTERVAL.
+		log.Fatalf("oci: OCI_RESYNC_INTERVAL must be positive, got %s", ociConfig.ResyncInterval)
+	}
+
+	ociRuntimes = buildOciRuntimes(ociConfig.OciContainerDir, ociConfig.Runtimes)
 }
 
 // ----------------------------------------------------------------------------
 // OCI container configuration inotify event to ociEvent state machine
 // ----------------------------------------------------------------------------
 
-func onOciConfigUpdate(configPath string) (*ociEvent, error) {
-	//
-	// Look for the close of an open for write to identify container started
-	// events.
-	//
-
+// onOciConfigUpdate parses a container's config.json. The returned event's
+// State is always ociRunning: for docker/libcontainerd, which has no
+// state.json, config.json's close-for-write is itself the started signal.
+// Other backends report their actual lifecycle via state.json instead, so
+// callers with one (i.e. everyone but docker) must not treat this State as
+// authoritative — see dispatchInotifyEvent's "config.json" case, which
+// discards it for every backend but docker.
+func (o *oci) onOciConfigUpdate(backend *ociBackend, configPath string) (*ociEvent, error) {
 	data, err := ioutil.ReadFile(configPath)
 	if err != nil {
 		return nil, err
@@ -73,43 +281,749 @@ func onOciConfigUpdate(configPath string) (*ociEvent, error) {
 	containerID := filepath.Base(filepath.Dir(configPath))
 
 	ev := &ociEvent{
+		Runtime:    backend.Name,
 		ID:         containerID,
 		State:      ociRunning,
 		ConfigJSON: string(data),
 	}
 
+	var spec specs.Spec
+	if err := json.Unmarshal(data, &spec); err == nil {
+		ev.Spec = &spec
+		ev.Annotations = spec.Annotations
+		ev.Hostname = spec.Hostname
+
+		if spec.Root != nil {
+			ev.RootPath = spec.Root.Path
+		}
+
+		if spec.Process != nil {
+			ev.Args = spec.Process.Args
+			ev.Env = spec.Process.Env
+		}
+
+		ev.Mounts = spec.Mounts
+
+		if spec.Linux != nil {
+			ev.Namespaces = spec.Linux.Namespaces
+			ev.CgroupsPath = spec.Linux.CgroupsPath
+			o.containerCgroups[containerID] = spec.Linux.CgroupsPath
+		}
+	}
+
+	// Cache ev so the Spec-derived fields it just parsed can be merged
+	// into the later state.json-derived event that actually reports
+	// this container's Created/Running/Stopped transitions (see
+	// mergeSpecFields).
+	o.containerSpecInfo[containerID] = ev
+
 	return ev, nil
 }
 
-func onOciConfigDelete(configPath string) (*ociEvent, error) {
+// mergeSpecFields copies the Spec-derived fields onOciConfigUpdate
+// populates (ConfigJSON, Spec, Annotations, Args, Env, RootPath, Mounts,
+// Namespaces, CgroupsPath, Hostname) from src into dst, if src is
+// non-nil. It's used to carry those fields over onto the state.json-derived
+// event backends other than docker actually report their lifecycle
+// through, since onOciStateUpdate has no access to config.json itself.
+func mergeSpecFields(dst, src *ociEvent) {
+	if src == nil {
+		return
+	}
+
+	dst.ConfigJSON = src.ConfigJSON
+	dst.Spec = src.Spec
+	dst.Annotations = src.Annotations
+	dst.Args = src.Args
+	dst.Env = src.Env
+	dst.RootPath = src.RootPath
+	dst.Mounts = src.Mounts
+	dst.Namespaces = src.Namespaces
+	dst.CgroupsPath = src.CgroupsPath
+	dst.Hostname = src.Hostname
+}
+
+func (o *oci) onOciConfigDelete(backend *ociBackend, configPath string) (*ociEvent, error) {
 	//
 	// Look for deletion of config.json to identify container stopped events.
 	//
 	containerID := filepath.Base(filepath.Dir(configPath))
 
 	ev := &ociEvent{
-		ID:    containerID,
-		State: ociStopped,
+		Runtime: backend.Name,
+		ID:      containerID,
+		State:   ociStopped,
+	}
+
+	return ev, nil
+}
+
+// onOciStateUpdate reads a runtime's state.json and translates its Status
+// into the matching ociState.
+func (o *oci) onOciStateUpdate(backend *ociBackend, statePath string) (*ociEvent, error) {
+	data, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state specs.State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	containerID := state.ID
+	if containerID == "" {
+		containerID = filepath.Base(filepath.Dir(statePath))
+	}
+
+	ev := &ociEvent{
+		Runtime: backend.Name,
+		ID:      containerID,
+	}
+
+	switch state.Status {
+	case specs.StateCreated:
+		ev.State = ociCreated
+	case specs.StateRunning:
+		ev.State = ociRunning
+	case specs.StateStopped:
+		ev.State = ociStopped
+	default:
+		ev.State = ociCreating
+	}
+
+	// state.json carries none of config.json's Spec-derived fields, but
+	// onOciConfigUpdate cached them the last time it ran for this
+	// container (see mergeSpecFields); merge them in so the event that
+	// actually reports this container's lifecycle for every backend but
+	// docker isn't missing its Annotations/Args/Env/Mounts/etc.
+	mergeSpecFields(ev, o.containerSpecInfo[containerID])
+
+	return ev, nil
+}
+
+// onOciExit reads a runtime's exit file (conmon's shared "<ExitDir>/<id>"
+// or runc's flat "exit" file inside the container's own directory) and
+// reports how the container terminated. containerID is passed in rather
+// than derived from exitPath, since conmon's layout names the file after
+// the container but runc's names the directory after it.
+func (o *oci) onOciExit(backend *ociBackend, containerID, exitPath string) (*ociEvent, error) {
+	ev := &ociEvent{
+		Runtime: backend.Name,
+		ID:      containerID,
+		State:   ociStopped,
+	}
+
+	o.populateExitInfo(ev, containerID, exitPath)
+
+	return ev, nil
+}
+
+// populateExitInfo fills in ev.ExitCode, ev.OOMKilled and ev.ErrorMessage
+// for a Stopped container from its exit file (if readable) and its cgroup
+// memory controller.
+func (o *oci) populateExitInfo(ev *ociEvent, containerID, exitPath string) {
+	if code, err := readExitCode(exitPath); err == nil {
+		ev.ExitCode = code
+	}
+
+	if checkOOMKilled(o.containerCgroups[containerID]) {
+		ev.OOMKilled = true
+		ev.ErrorMessage = "container killed by the kernel OOM killer"
+	} else if ev.ExitCode != 0 {
+		ev.ErrorMessage = fmt.Sprintf("container exited with code %d", ev.ExitCode)
+	}
+}
+
+// execPidPrefix and execSyncPrefix are conmon's naming convention for the
+// pidfile it writes when an exec session starts and the file it writes with
+// the session's exit status when the session ends.
+const (
+	execPidPrefix  = "exec-pid-"
+	execSyncPrefix = "exec-sync-"
+)
+
+// onOciExecStart reads the pidfile conmon writes when a "runc exec"/"crun
+// exec" session starts inside a container.
+func (o *oci) onOciExecStart(backend *ociBackend, pidPath string) (*ociEvent, error) {
+	dir := filepath.Dir(pidPath)
+	containerID := filepath.Base(dir)
+	sessionID := strings.TrimPrefix(filepath.Base(pidPath), execPidPrefix)
+
+	ev := &ociEvent{
+		Runtime:   backend.Name,
+		ID:        containerID,
+		State:     ociExecStarted,
+		SessionID: sessionID,
+	}
+
+	data, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32); err == nil {
+		ev.PID = int32(pid)
+	}
+
+	if argv, err := readExecArgv(dir, sessionID); err == nil {
+		ev.Argv = argv
 	}
 
 	return ev, nil
 }
 
+// onOciExecExit reads the exit-status file conmon writes when an exec
+// session ends.
+func (o *oci) onOciExecExit(backend *ociBackend, syncPath string) (*ociEvent, error) {
+	containerID := filepath.Base(filepath.Dir(syncPath))
+	sessionID := strings.TrimPrefix(filepath.Base(syncPath), execSyncPrefix)
+
+	ev := &ociEvent{
+		Runtime:   backend.Name,
+		ID:        containerID,
+		State:     ociExecExited,
+		SessionID: sessionID,
+	}
+
+	if code, err := readExitCode(syncPath); err == nil {
+		ev.ExitCode = code
+	}
+
+	return ev, nil
+}
+
+// readExecArgv looks for the exec session's process spec,
+// "exec-process-<sessionID>.json" in containerDir alongside the pidfile, and
+// returns its Args, when present.
+func readExecArgv(containerDir, sessionID string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(containerDir, "exec-process-"+sessionID+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var process specs.Process
+	if err := json.Unmarshal(data, &process); err != nil {
+		return nil, err
+	}
+
+	return process.Args, nil
+}
+
+func readExitCode(exitPath string) (int32, error) {
+	data, err := ioutil.ReadFile(exitPath)
+	if err != nil {
+		return 0, err
+	}
+
+	code, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(code), nil
+}
+
+// cgroupMemoryRoots are searched, in order, for a container's memory
+// controller: the cgroup v2 unified hierarchy first, then the v1 memory
+// hierarchy.
+var cgroupMemoryRoots = []string{"/sys/fs/cgroup", "/sys/fs/cgroup/memory"}
+
+// checkOOMKilled reports whether the kernel OOM killer has fired for the
+// cgroup at cgroupsPath, by checking cgroup v2's memory.events and cgroup
+// v1's memory.oom_control.
+func checkOOMKilled(cgroupsPath string) bool {
+	if cgroupsPath == "" {
+		return false
+	}
+
+	for _, root := range cgroupMemoryRoots {
+		dir := filepath.Join(root, cgroupsPath)
+
+		if oomKilledInEvents(filepath.Join(dir, "memory.events")) {
+			return true
+		}
+
+		if oomKilledInControl(filepath.Join(dir, "memory.oom_control")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func oomKilledInEvents(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" && fields[1] != "0" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func oomKilledInControl(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		if (fields[0] == "oom_kill" && fields[1] != "0") ||
+			(fields[0] == "under_oom" && fields[1] == "1") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ociWatcher is the subset of *inotify.Instance the OCI sensor needs to
+// register and tear down watches. It lets tests exercise reconcileDir/
+// reconcileContainer/resync against a real filesystem without opening an
+// actual inotify file descriptor.
+type ociWatcher interface {
+	AddWatch(path string, mask uint32) error
+	RemoveWatch(path string) error
+}
+
+// watchedDir tracks why a directory is being watched: which backend it
+// belongs to, how many more directory levels remain before reaching
+// container-ID directories (see ociStateDir.NamespaceDepth), and which
+// single entry name the next level must match, if any (see
+// ociStateDir.NamespaceName).
+type watchedDir struct {
+	backend       *ociBackend
+	remaining     int
+	namespaceName string
+}
+
+func (o *oci) watchDir(dir string, backend *ociBackend, remaining int, namespaceName string) {
+	if err := o.inotify.AddWatch(dir, unix.IN_CREATE); err != nil {
+		log.Printf("oci: failed to watch %s (%s): %v", dir, backend.Name, err)
+		return
+	}
+
+	o.watched[dir] = &watchedDir{backend: backend, remaining: remaining, namespaceName: namespaceName}
+}
+
+// addWatches starts watching every configured directory of backend for
+// newly created container (or namespace) directories, plus backend's
+// shared exit directory, if any, for conmon's per-container exit files.
+func (o *oci) addWatches(backend *ociBackend) {
+	for _, dir := range backend.Dirs {
+		o.watchDir(dir.Path, backend, dir.NamespaceDepth, dir.NamespaceName)
+	}
+
+	if backend.ExitDir != "" {
+		if err := o.inotify.AddWatch(backend.ExitDir, unix.IN_CREATE); err != nil {
+			log.Printf("oci: failed to watch %s (%s): %v", backend.ExitDir, backend.Name, err)
+			return
+		}
+
+		o.exitDirs[backend.ExitDir] = backend
+	}
+}
+
+// ociExitInfo is the subset of an ociStopped ociEvent that reconcile/resync
+// compares against to decide whether a container's exit has actually
+// changed since it was last reported (see recordState, reconcileContainer).
+type ociExitInfo struct {
+	ExitCode  int32
+	OOMKilled bool
+}
+
+// recordState updates o.lastKnownState (and, for ociStopped, o.lastExitInfo)
+// for ev's container lifecycle state (ignoring ev if it describes an exec
+// session rather than the container itself), so a later reconcile/resync
+// can tell whether anything has actually changed.
+func (o *oci) recordState(ev *ociEvent) {
+	switch ev.State {
+	case ociDeleted:
+		delete(o.lastKnownState, ev.ID)
+		delete(o.lastExitInfo, ev.ID)
+	case ociStopped:
+		o.lastKnownState[ev.ID] = ev.State
+		o.lastExitInfo[ev.ID] = ociExitInfo{ExitCode: ev.ExitCode, OOMKilled: ev.OOMKilled}
+	case ociCreating, ociCreated, ociRunning:
+		o.lastKnownState[ev.ID] = ev.State
+	}
+}
+
+// emit records ev's state and sends it downstream.
+func (o *oci) emit(ev *ociEvent) {
+	o.recordState(ev)
+	o.data <- ev
+}
+
+// reconcile walks backend's state directories for containers that already
+// exist, so NewOciEventStream consumers see them even if the sensor started
+// after they were created, or to recover from inotify events missed during
+// a resync.
+func (o *oci) reconcile(backend *ociBackend) {
+	for _, dir := range backend.Dirs {
+		o.reconcileDir(backend, dir.Path, dir.NamespaceDepth, dir.NamespaceName)
+	}
+}
+
+func (o *oci) reconcileDir(backend *ociBackend, dir string, remaining int, namespaceName string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		if remaining > 0 {
+			if !isNamespaceDir(entry.Name(), namespaceName) {
+				// Not the namespace directory (e.g. containers/storage's
+				// "overlay", "overlay-layers", "mounts" siblings to
+				// "overlay-containers"). Mirrors the name check the live
+				// dispatch path applies before watching a new directory
+				// as a namespace (see dispatchInotifyEvent).
+				continue
+			}
+
+			// The namespace directory itself may already have existed
+			// before the sensor started, in which case addWatches never
+			// saw it get created and it has no watch yet. Register one
+			// now so containers created under it afterward are still
+			// caught live, the same way addWatches does for dir.
+			o.watchDir(path, backend, remaining-1, "")
+			o.reconcileDir(backend, path, remaining-1, "")
+			continue
+		}
+
+		if !isContainerID(entry.Name()) {
+			// Not a container-ID directory (e.g. containers/storage's
+			// "overlay", "overlay-layers", "mounts" siblings to
+			// "overlay-containers"). Mirrors the shape check the live
+			// dispatch path applies before treating a new directory as
+			// a container (see dispatchInotifyEvent).
+			continue
+		}
+
+		o.reconcileContainer(backend, path)
+	}
+}
+
+// reconcileContainer watches an already-existing container directory and
+// synthesizes the ociCreating/ociCreated/ociRunning/ociStopped event its current
+// on-disk state implies, unless that's exactly the state we last reported
+// for it (so a resync doesn't re-announce containers nothing happened to).
+func (o *oci) reconcileContainer(backend *ociBackend, containerDir string) {
+	containerID := filepath.Base(containerDir)
+
+	if err := o.inotify.AddWatch(containerDir, uint32(containerWatchMask)); err != nil {
+		log.Printf("oci: failed to watch %s (%s): %v", containerDir, backend.Name, err)
+		return
+	}
+	o.containerBackend[containerDir] = backend
+
+	// Always parse config.json: besides being the update used below when
+	// state.json is unavailable, it's the only place that records the
+	// container's cgroups path, which exit handling needs to tell OOM
+	// kills apart from ordinary exits, and it populates containerSpecInfo
+	// so onOciStateUpdate below can merge its Spec-derived fields onto
+	// the event it builds from state.json.
+	configPath := filepath.Join(containerDir, "config.json")
+	configEv, configErr := o.onOciConfigUpdate(backend, configPath)
+
+	var ev *ociEvent
+
+	statePath := filepath.Join(containerDir, "state.json")
+	if stateEv, err := o.onOciStateUpdate(backend, statePath); err == nil {
+		ev = stateEv
+	} else if configErr == nil {
+		ev = configEv
+	} else {
+		// Neither state.json nor config.json could be read yet; the
+		// container is still being created.
+		ev = &ociEvent{Runtime: backend.Name, ID: containerID, State: ociCreating}
+	}
+
+	if ev.State == ociStopped {
+		exitPath := filepath.Join(containerDir, "exit")
+		if backend.ExitDir != "" {
+			exitPath = filepath.Join(backend.ExitDir, containerID)
+		}
+		o.populateExitInfo(ev, containerID, exitPath)
+
+		// Unlike the other states, re-check every time: the exit file
+		// or cgroup accounting a stopped container's ExitCode/OOMKilled
+		// come from may not have settled on the first resync that
+		// observes it. Still skip re-emitting if nothing came of that
+		// recheck, so a container that stays stopped-but-not-removed
+		// doesn't get a fresh duplicate event every resync forever.
+		if last, ok := o.lastKnownState[containerID]; ok && last == ociStopped {
+			if prev, ok := o.lastExitInfo[containerID]; ok &&
+				prev.ExitCode == ev.ExitCode && prev.OOMKilled == ev.OOMKilled {
+				return
+			}
+		}
+	} else if last, ok := o.lastKnownState[containerID]; ok && last == ev.State {
+		return
+	}
+
+	o.emit(ev)
+}
+
+// resync recovers from missed inotify events (e.g. an IN_Q_OVERFLOW) by
+// dropping every watch, re-walking all backends' state directories, and
+// diffing the result against what was previously known: containers that
+// disappeared while events were being missed get a synthetic ociDeleted
+// event, containers whose state changed get the transition reconcile
+// detects, and containers found unchanged are left alone.
+func (o *oci) resync() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	known := make(map[string]*ociBackend, len(o.containerBackend))
+	for dir, backend := range o.containerBackend {
+		known[filepath.Base(dir)] = backend
+	}
+
+	for dir := range o.watched {
+		o.inotify.RemoveWatch(dir)
+	}
+	for dir := range o.containerBackend {
+		o.inotify.RemoveWatch(dir)
+	}
+	for dir := range o.exitDirs {
+		o.inotify.RemoveWatch(dir)
+	}
+
+	o.watched = make(map[string]*watchedDir)
+	o.containerBackend = make(map[string]*ociBackend)
+	o.containerCgroups = make(map[string]string)
+	o.containerSpecInfo = make(map[string]*ociEvent)
+	o.exitDirs = make(map[string]*ociBackend)
+
+	for _, backend := range ociRuntimes {
+		o.addWatches(backend)
+		o.reconcile(backend)
+	}
+
+	seen := make(map[string]bool, len(o.containerBackend))
+	for dir := range o.containerBackend {
+		seen[filepath.Base(dir)] = true
+	}
+
+	for id, backend := range known {
+		if !seen[id] {
+			o.emit(&ociEvent{Runtime: backend.Name, ID: id, State: ociDeleted})
+		}
+	}
+}
+
+// containerWatchMask is applied to every discovered container directory.
+// IN_CLOSE_WRITE/IN_DELETE catch config.json and state.json updates,
+// IN_CREATE catches new exit/exec pidfiles, and IN_DELETE_SELF catches the
+// container directory itself being removed once the container is reaped.
+const containerWatchMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_DELETE_SELF
+
+// containerIDLength is the length of the hex container IDs every supported
+// runtime uses (a full SHA-256 digest).
+const containerIDLength = 64
+
+// isContainerID reports whether name has the shape of a container ID,
+// distinguishing container directories from non-container siblings under
+// the same state-directory root (e.g. containers/storage's "overlay",
+// "overlay-layers" and "mounts" alongside "overlay-containers").
+func isContainerID(name string) bool {
+	return len(name) == containerIDLength
+}
+
+// isNamespaceDir reports whether entryName is a namespace directory worth
+// descending into for an ociStateDir with the given NamespaceName: any
+// entry when namespaceName is empty (containerd's namespaces are
+// arbitrarily named), otherwise only the one entry matching namespaceName
+// exactly (containers/storage's fixed "overlay-containers", to the
+// exclusion of its "overlay"/"overlay-layers"/"mounts" siblings).
+func isNamespaceDir(entryName, namespaceName string) bool {
+	return namespaceName == "" || entryName == namespaceName
+}
+
+// onInotifyEvent translates a raw inotify event into an ociEvent (if any)
+// and records its resulting state, all under o.mu so it can't race with a
+// concurrent reconcile/resync.
 func (o *oci) onInotifyEvent(iev *inotify.Event) *ociEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ev := o.dispatchInotifyEvent(iev)
+	if ev != nil {
+		o.recordState(ev)
+	}
+
+	return ev
+}
+
+func (o *oci) dispatchInotifyEvent(iev *inotify.Event) *ociEvent {
+	if iev.Mask&unix.IN_DELETE_SELF != 0 {
+		backend := o.containerBackend[iev.Path]
+		if backend == nil {
+			return nil
+		}
+
+		containerID := filepath.Base(iev.Path)
+		delete(o.containerBackend, iev.Path)
+		delete(o.containerCgroups, containerID)
+		delete(o.containerSpecInfo, containerID)
+
+		return &ociEvent{Runtime: backend.Name, ID: containerID, State: ociDeleted}
+	}
+
 	dir := filepath.Dir(iev.Path)
 
-	if iev.Name == "config.json" {
+	switch iev.Name {
+	case "config.json":
+		backend := o.containerBackend[dir]
+		if backend == nil {
+			return nil
+		}
+
 		if iev.Mask&unix.IN_CLOSE_WRITE != 0 {
-			ev, _ := onOciConfigUpdate(iev.Path)
+			ev, err := o.onOciConfigUpdate(backend, iev.Path)
+			if err != nil {
+				return nil
+			}
+
+			// Only docker/libcontainerd lacks a state.json lifecycle;
+			// for every other backend config.json is written once at
+			// create time, before the runtime actually starts the
+			// process, so onOciConfigUpdate's ociRunning would be a
+			// spurious, premature transition. state.json (below)
+			// reports those backends' real Created/Running/Stopped
+			// transitions instead; here we still parse config.json to
+			// pick up ev.Spec/cgroups, but drop the synthesized event.
+			if backend.Name != "docker" {
+				return nil
+			}
+
 			return ev
 
 		} else if iev.Mask&unix.IN_DELETE != 0 {
-			ev, _ := onOciConfigDelete(iev.Path)
+			ev, _ := o.onOciConfigDelete(backend, iev.Path)
 			return ev
 		}
-	} else if dir == ociConfig.OciContainerDir && len(iev.Name) == 64 {
-		mask := unix.IN_CLOSE_WRITE | unix.IN_DELETE
-		o.inotify.AddWatch(iev.Path, uint32(mask))
+
+		return nil
+
+	case "state.json":
+		if iev.Mask&unix.IN_CLOSE_WRITE == 0 {
+			return nil
+		}
+
+		backend := o.containerBackend[dir]
+		if backend == nil {
+			return nil
+		}
+
+		ev, _ := o.onOciStateUpdate(backend, iev.Path)
+		return ev
+
+	case "exit":
+		if iev.Mask&unix.IN_CREATE == 0 {
+			return nil
+		}
+
+		backend := o.containerBackend[dir]
+		if backend == nil {
+			return nil
+		}
+
+		containerID := filepath.Base(dir)
+		ev, _ := o.onOciExit(backend, containerID, iev.Path)
+		return ev
+	}
+
+	// conmon-based runtimes (CRI-O, podman) write exit status to a
+	// shared directory, named by container ID, rather than a flat
+	// "exit" file inside the container's own directory.
+	if backend := o.exitDirs[dir]; backend != nil {
+		if iev.Mask&unix.IN_CREATE == 0 {
+			return nil
+		}
+
+		ev, _ := o.onOciExit(backend, iev.Name, iev.Path)
+		return ev
+	}
+
+	if strings.HasPrefix(iev.Name, execPidPrefix) {
+		if iev.Mask&unix.IN_CREATE == 0 {
+			return nil
+		}
+
+		backend := o.containerBackend[dir]
+		if backend == nil {
+			return nil
+		}
+
+		ev, _ := o.onOciExecStart(backend, iev.Path)
+		return ev
+	}
+
+	if strings.HasPrefix(iev.Name, execSyncPrefix) {
+		if iev.Mask&unix.IN_CREATE == 0 {
+			return nil
+		}
+
+		backend := o.containerBackend[dir]
+		if backend == nil {
+			return nil
+		}
+
+		ev, _ := o.onOciExecExit(backend, iev.Path)
+		return ev
+	}
+
+	wd, ok := o.watched[dir]
+	if !ok {
+		return nil
+	}
+
+	if wd.remaining > 0 {
+		if !isNamespaceDir(iev.Name, wd.namespaceName) {
+			// Not the namespace directory (e.g. containers/storage's
+			// "overlay", "overlay-layers", "mounts" siblings to
+			// "overlay-containers"); ignore it rather than watching it
+			// as if container directories lived under it.
+			return nil
+		}
+
+		// iev.Path is an intermediate directory (e.g. containerd's
+		// per-namespace directory); keep descending until container-ID
+		// directories are reached.
+		o.watchDir(iev.Path, wd.backend, wd.remaining-1, "")
+		return nil
+	}
+
+	if isContainerID(iev.Name) {
+		if err := o.inotify.AddWatch(iev.Path, uint32(containerWatchMask)); err != nil {
+			log.Printf("oci: failed to watch %s (%s): %v", iev.Path, wd.backend.Name, err)
+			return nil
+		}
+
+		o.containerBackend[iev.Path] = wd.backend
+
+		return &ociEvent{Runtime: wd.backend.Name, ID: iev.Name, State: ociCreating}
 	}
 
 	return nil
@@ -126,10 +1040,62 @@ type oci struct {
 	ctrl          chan interface{}
 	data          chan interface{}
 	eventStream   *stream.Stream
-	inotify       *inotify.Instance
+	inotify       ociWatcher
 	inotifyEvents *stream.Stream
 	inotifyDone   chan interface{}
 	repeater      *stream.Repeater
+
+	// mu guards watched, containerBackend, containerCgroups,
+	// containerSpecInfo, exitDirs, lastKnownState and lastExitInfo
+	// below, all of which are mutated
+	// both by the inotify-event goroutine (handleInotifyEvent, via
+	// stream.ForEach, including its resync on an IN_Q_OVERFLOW) and by
+	// the sensor's own goroutine on a periodic resync (triggered from
+	// loop() by resyncTicker).
+	mu sync.Mutex
+
+	// watched maps a watched directory to the backend/depth it belongs
+	// to (see watchDir).
+	watched map[string]*watchedDir
+
+	// containerBackend maps a watched container directory to the
+	// backend that owns it, so config.json events can be tagged with
+	// the right runtime.
+	containerBackend map[string]*ociBackend
+
+	// containerCgroups maps a container ID to its cgroups path (parsed
+	// from config.json), used to check for OOM kills when the container
+	// exits.
+	containerCgroups map[string]string
+
+	// containerSpecInfo caches the most recent event onOciConfigUpdate
+	// built for a container ID, so the Spec-derived fields it parsed out
+	// of config.json (Annotations, Args, Env, Mounts, Namespaces,
+	// CgroupsPath, Hostname) can be merged into the state.json-derived
+	// event that actually reports Created/Running/Stopped for every
+	// backend but docker (see mergeSpecFields, dispatchInotifyEvent's
+	// "state.json" case).
+	containerSpecInfo map[string]*ociEvent
+
+	// exitDirs maps a watched shared exit directory (ociBackend.ExitDir)
+	// to the backend it belongs to, so a file created inside it can be
+	// tagged with the right runtime (see dispatchInotifyEvent).
+	exitDirs map[string]*ociBackend
+
+	// lastKnownState records the State of the most recent event emitted
+	// for each container ID, so reconcile/resync can tell whether a
+	// container's state actually changed since it was last reported.
+	lastKnownState map[string]ociState
+
+	// lastExitInfo records the ExitCode/OOMKilled of the most recent
+	// ociStopped event emitted for each container ID, so reconcile/
+	// resync can tell whether a still-stopped container's exit info
+	// actually changed since it was last reported.
+	lastExitInfo map[string]ociExitInfo
+
+	// resyncTicker periodically triggers resync() to recover from
+	// inotify events missed under load.
+	resyncTicker *time.Ticker
 }
 
 var ociOnce sync.Once
@@ -162,6 +1128,9 @@ func (o *oci) loop() (bool, error) {
 		} else {
 			// control channel was closed, shut down
 		}
+
+	case <-o.resyncTicker.C:
+		o.resync()
 	}
 
 	return true, nil
@@ -170,6 +1139,15 @@ func (o *oci) loop() (bool, error) {
 func (o *oci) handleInotifyEvent(e interface{}) {
 	iev := e.(*inotify.Event)
 
+	if iev.Mask&unix.IN_Q_OVERFLOW != 0 {
+		// The kernel dropped events because our queue filled up; we
+		// can no longer trust incremental state and must resync from
+		// scratch.
+		log.Printf("oci: inotify queue overflowed, resyncing")
+		o.resync()
+		return
+	}
+
 	ev := o.onInotifyEvent(iev)
 	if ev != nil {
 		o.data <- ev
@@ -210,7 +1188,15 @@ func initializeOciSensor() error {
 				Data: data,
 			},
 
-			inotify: in,
+			inotify:           in,
+			watched:           make(map[string]*watchedDir),
+			containerBackend:  make(map[string]*ociBackend),
+			containerCgroups:  make(map[string]string),
+			containerSpecInfo: make(map[string]*ociEvent),
+			exitDirs:          make(map[string]*ociBackend),
+			lastKnownState:    make(map[string]ociState),
+			lastExitInfo:      make(map[string]ociExitInfo),
+			resyncTicker:      time.NewTicker(ociConfig.ResyncInterval),
 		}
 
 		o.inotifyEvents = in.Events()
@@ -219,7 +1205,12 @@ func initializeOciSensor() error {
 
 		o.repeater = stream.NewRepeater(o.eventStream)
 
-		addWatches(ociConfig.OciContainerDir, o.inotify)
+		o.mu.Lock()
+		for _, backend := range ociRuntimes {
+			o.addWatches(backend)
+			o.reconcile(backend)
+		}
+		o.mu.Unlock()
 
 		for {
 			var ok bool
@@ -0,0 +1,142 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestOci() *oci {
+	return &oci{
+		containerCgroups:  make(map[string]string),
+		containerSpecInfo: make(map[string]*ociEvent),
+	}
+}
+
+func writeConfigJSON(t *testing.T, dir, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestOnOciConfigUpdateExtractsSpecFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-abcdef0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const spec = `{
+		"annotations": {"io.kubernetes.pod.uid": "abc-123"},
+		"hostname": "my-container",
+		"root": {"path": "rootfs"},
+		"process": {"args": ["/bin/sh", "-c", "sleep 1"], "env": ["PATH=/bin"]},
+		"mounts": [{"destination": "/proc", "type": "proc"}],
+		"linux": {
+			"namespaces": [{"type": "pid"}],
+			"cgroupsPath": "/kubepods/burstable/pod1/container1"
+		}
+	}`
+
+	configPath := writeConfigJSON(t, dir, spec)
+	containerID := filepath.Base(dir)
+
+	o := newTestOci()
+	backend := &ociBackend{Name: "crio"}
+
+	ev, err := o.onOciConfigUpdate(backend, configPath)
+	if err != nil {
+		t.Fatalf("onOciConfigUpdate: %v", err)
+	}
+
+	if ev.Runtime != "crio" || ev.ID != containerID || ev.State != ociRunning {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	if ev.Spec == nil {
+		t.Fatal("expected Spec to be populated")
+	}
+
+	if ev.Hostname != "my-container" {
+		t.Errorf("Hostname = %q, want %q", ev.Hostname, "my-container")
+	}
+
+	if ev.RootPath != "rootfs" {
+		t.Errorf("RootPath = %q, want %q", ev.RootPath, "rootfs")
+	}
+
+	if len(ev.Args) != 3 || ev.Args[2] != "sleep 1" {
+		t.Errorf("Args = %v", ev.Args)
+	}
+
+	if len(ev.Env) != 1 || ev.Env[0] != "PATH=/bin" {
+		t.Errorf("Env = %v", ev.Env)
+	}
+
+	if len(ev.Mounts) != 1 || ev.Mounts[0].Destination != "/proc" {
+		t.Errorf("Mounts = %v", ev.Mounts)
+	}
+
+	if len(ev.Namespaces) != 1 {
+		t.Errorf("Namespaces = %v", ev.Namespaces)
+	}
+
+	if ev.CgroupsPath != "/kubepods/burstable/pod1/container1" {
+		t.Errorf("CgroupsPath = %q", ev.CgroupsPath)
+	}
+
+	if got := ev.Annotations["io.kubernetes.pod.uid"]; got != "abc-123" {
+		t.Errorf("Annotations[pod.uid] = %q", got)
+	}
+
+	if got := o.containerCgroups[containerID]; got != ev.CgroupsPath {
+		t.Errorf("containerCgroups[%s] = %q, want %q", containerID, got, ev.CgroupsPath)
+	}
+}
+
+func TestOnOciConfigUpdateFallsBackOnUnparseableSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-abcdef0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := writeConfigJSON(t, dir, "not valid json")
+
+	o := newTestOci()
+	ev, err := o.onOciConfigUpdate(&ociBackend{Name: "docker"}, configPath)
+	if err != nil {
+		t.Fatalf("onOciConfigUpdate: %v", err)
+	}
+
+	if ev.Spec != nil {
+		t.Errorf("expected nil Spec, got %+v", ev.Spec)
+	}
+
+	if ev.ConfigJSON != "not valid json" {
+		t.Errorf("ConfigJSON = %q", ev.ConfigJSON)
+	}
+
+	if ev.State != ociRunning {
+		t.Errorf("State = %v, want ociRunning", ev.State)
+	}
+}
+
+func TestOnOciConfigDelete(t *testing.T) {
+	o := newTestOci()
+
+	ev, err := o.onOciConfigDelete(&ociBackend{Name: "podman"}, "/var/lib/containers/storage/abc123/config.json")
+	if err != nil {
+		t.Fatalf("onOciConfigDelete: %v", err)
+	}
+
+	if ev.ID != "abc123" || ev.State != ociStopped || ev.Runtime != "podman" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
@@ -0,0 +1,258 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/capsule8/reactive8/pkg/inotify"
+)
+
+func newTestOciForDispatch() *oci {
+	return &oci{
+		containerBackend:  make(map[string]*ociBackend),
+		containerCgroups:  make(map[string]string),
+		containerSpecInfo: make(map[string]*ociEvent),
+		exitDirs:          make(map[string]*ociBackend),
+		watched:           make(map[string]*watchedDir),
+		lastKnownState:    make(map[string]ociState),
+		lastExitInfo:      make(map[string]ociExitInfo),
+	}
+}
+
+func TestDispatchInotifyEventConfigJSONDockerEmitsRunning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-dispatch-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := writeConfigJSON(t, dir, `{"hostname":"h1"}`)
+
+	o := newTestOciForDispatch()
+	o.containerBackend[dir] = &ociBackend{Name: "docker"}
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_CLOSE_WRITE,
+		Name: "config.json",
+		Path: configPath,
+	})
+
+	if ev == nil || ev.State != ociRunning {
+		t.Fatalf("dispatchInotifyEvent = %+v, want ociRunning", ev)
+	}
+}
+
+func TestDispatchInotifyEventConfigJSONNonDockerSuppressesRunning(t *testing.T) {
+	for _, name := range []string{"containerd", "crio", "podman"} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "oci-test-dispatch-nondocker")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			configPath := writeConfigJSON(t, dir, `{"linux":{"cgroupsPath":"/kubepods/p/c"}}`)
+			containerID := filepath.Base(dir)
+
+			o := newTestOciForDispatch()
+			o.containerBackend[dir] = &ociBackend{Name: name}
+
+			ev := o.dispatchInotifyEvent(&inotify.Event{
+				Mask: unix.IN_CLOSE_WRITE,
+				Name: "config.json",
+				Path: configPath,
+			})
+
+			if ev != nil {
+				t.Fatalf("dispatchInotifyEvent = %+v, want nil (state.json owns this backend's lifecycle)", ev)
+			}
+
+			if got := o.containerCgroups[containerID]; got != "/kubepods/p/c" {
+				t.Errorf("containerCgroups[%s] = %q, want config.json still parsed for side effects", containerID, got)
+			}
+		})
+	}
+}
+
+func TestDispatchInotifyEventConfigJSONDelete(t *testing.T) {
+	o := newTestOciForDispatch()
+	dir := "/var/lib/containers/storage/abc123"
+	o.containerBackend[dir] = &ociBackend{Name: "podman"}
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_DELETE,
+		Name: "config.json",
+		Path: filepath.Join(dir, "config.json"),
+	})
+
+	if ev == nil || ev.State != ociStopped || ev.ID != "abc123" {
+		t.Fatalf("dispatchInotifyEvent = %+v, want ociStopped for abc123", ev)
+	}
+}
+
+func TestDispatchInotifyEventStateJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-dispatch-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	statePath := writeStateJSON(t, dir, `{"ociVersion":"1.0.0","id":"c1","status":"running"}`)
+
+	o := newTestOciForDispatch()
+	o.containerBackend[dir] = &ociBackend{Name: "runc"}
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_CLOSE_WRITE,
+		Name: "state.json",
+		Path: statePath,
+	})
+
+	if ev == nil || ev.State != ociRunning || ev.ID != "c1" {
+		t.Fatalf("dispatchInotifyEvent = %+v, want ociRunning for c1", ev)
+	}
+}
+
+func TestDispatchInotifyEventFlatExitFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-dispatch-exit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	containerID := filepath.Base(dir)
+	exitPath := filepath.Join(dir, "exit")
+	if err := ioutil.WriteFile(exitPath, []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOciForDispatch()
+	o.containerBackend[dir] = &ociBackend{Name: "runc"}
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_CREATE,
+		Name: "exit",
+		Path: exitPath,
+	})
+
+	if ev == nil || ev.State != ociStopped || ev.ID != containerID {
+		t.Fatalf("dispatchInotifyEvent = %+v, want ociStopped for %s", ev, containerID)
+	}
+}
+
+func TestDispatchInotifyEventSharedExitDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-dispatch-exitdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	containerID := strings.Repeat("f", containerIDLength)
+	exitPath := filepath.Join(dir, containerID)
+	if err := ioutil.WriteFile(exitPath, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOciForDispatch()
+	o.exitDirs[dir] = &ociBackend{Name: "crio"}
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_CREATE,
+		Name: containerID,
+		Path: exitPath,
+	})
+
+	if ev == nil || ev.State != ociStopped || ev.ID != containerID {
+		t.Fatalf("dispatchInotifyEvent = %+v, want ociStopped for %s", ev, containerID)
+	}
+}
+
+func TestDispatchInotifyEventContainerDeleteSelf(t *testing.T) {
+	o := newTestOciForDispatch()
+	containerDir := "/var/run/docker/libcontainerd/abc123"
+	o.containerBackend[containerDir] = &ociBackend{Name: "docker"}
+	o.containerCgroups["abc123"] = "/docker/abc123"
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_DELETE_SELF,
+		Path: containerDir,
+	})
+
+	if ev == nil || ev.State != ociDeleted || ev.ID != "abc123" {
+		t.Fatalf("dispatchInotifyEvent = %+v, want ociDeleted for abc123", ev)
+	}
+
+	if _, ok := o.containerBackend[containerDir]; ok {
+		t.Error("expected containerBackend entry to be removed")
+	}
+
+	if _, ok := o.containerCgroups["abc123"]; ok {
+		t.Error("expected containerCgroups entry to be removed")
+	}
+}
+
+func TestDispatchInotifyEventUnwatchedDirReturnsNil(t *testing.T) {
+	o := newTestOciForDispatch()
+
+	ev := o.dispatchInotifyEvent(&inotify.Event{
+		Mask: unix.IN_CREATE,
+		Name: "some-file",
+		Path: "/tmp/not-watched/some-file",
+	})
+
+	if ev != nil {
+		t.Fatalf("dispatchInotifyEvent = %+v, want nil for an unwatched directory", ev)
+	}
+}
+
+func TestRecordStateTracksLatestPerContainer(t *testing.T) {
+	o := newTestOciForDispatch()
+
+	o.recordState(&ociEvent{ID: "c1", State: ociCreating})
+	if o.lastKnownState["c1"] != ociCreating {
+		t.Fatalf("lastKnownState[c1] = %v, want ociCreating", o.lastKnownState["c1"])
+	}
+
+	o.recordState(&ociEvent{ID: "c1", State: ociRunning})
+	if o.lastKnownState["c1"] != ociRunning {
+		t.Fatalf("lastKnownState[c1] = %v, want ociRunning", o.lastKnownState["c1"])
+	}
+
+	o.recordState(&ociEvent{ID: "c1", State: ociStopped, ExitCode: 137, OOMKilled: true})
+	if o.lastKnownState["c1"] != ociStopped {
+		t.Fatalf("lastKnownState[c1] = %v, want ociStopped", o.lastKnownState["c1"])
+	}
+
+	if info := o.lastExitInfo["c1"]; info.ExitCode != 137 || !info.OOMKilled {
+		t.Errorf("lastExitInfo[c1] = %+v, want {137 true}", info)
+	}
+}
+
+func TestRecordStateDeletedClearsBookkeeping(t *testing.T) {
+	o := newTestOciForDispatch()
+	o.recordState(&ociEvent{ID: "c1", State: ociStopped, ExitCode: 1})
+	o.recordState(&ociEvent{ID: "c1", State: ociDeleted})
+
+	if _, ok := o.lastKnownState["c1"]; ok {
+		t.Error("expected lastKnownState[c1] to be cleared on ociDeleted")
+	}
+
+	if _, ok := o.lastExitInfo["c1"]; ok {
+		t.Error("expected lastExitInfo[c1] to be cleared on ociDeleted")
+	}
+}
+
+func TestRecordStateIgnoresExecEvents(t *testing.T) {
+	o := newTestOciForDispatch()
+	o.recordState(&ociEvent{ID: "c1", State: ociRunning})
+	o.recordState(&ociEvent{ID: "c1", State: ociExecStarted, SessionID: "s1"})
+
+	if o.lastKnownState["c1"] != ociRunning {
+		t.Errorf("lastKnownState[c1] = %v, want ociRunning unaffected by an exec event", o.lastKnownState["c1"])
+	}
+}
@@ -0,0 +1,157 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnOciExecStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-exec-start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const sessionID = "abc123"
+	pidPath := filepath.Join(dir, execPidPrefix+sessionID)
+	if err := ioutil.WriteFile(pidPath, []byte("4242\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processPath := filepath.Join(dir, "exec-process-"+sessionID+".json")
+	if err := ioutil.WriteFile(processPath, []byte(`{"args":["/bin/sh","-c","echo hi"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOci()
+	ev, err := o.onOciExecStart(&ociBackend{Name: "crio"}, pidPath)
+	if err != nil {
+		t.Fatalf("onOciExecStart: %v", err)
+	}
+
+	if ev.State != ociExecStarted {
+		t.Errorf("State = %v, want ociExecStarted", ev.State)
+	}
+
+	if ev.ID != filepath.Base(dir) {
+		t.Errorf("ID = %q, want %q", ev.ID, filepath.Base(dir))
+	}
+
+	if ev.SessionID != sessionID {
+		t.Errorf("SessionID = %q, want %q", ev.SessionID, sessionID)
+	}
+
+	if ev.PID != 4242 {
+		t.Errorf("PID = %d, want 4242", ev.PID)
+	}
+
+	if len(ev.Argv) != 3 || ev.Argv[2] != "echo hi" {
+		t.Errorf("Argv = %v", ev.Argv)
+	}
+}
+
+func TestOnOciExecStartWithoutProcessJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-exec-start-noargv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const sessionID = "abc123"
+	pidPath := filepath.Join(dir, execPidPrefix+sessionID)
+	if err := ioutil.WriteFile(pidPath, []byte("99\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOci()
+	ev, err := o.onOciExecStart(&ociBackend{Name: "crio"}, pidPath)
+	if err != nil {
+		t.Fatalf("onOciExecStart: %v", err)
+	}
+
+	if ev.PID != 99 {
+		t.Errorf("PID = %d, want 99", ev.PID)
+	}
+
+	if ev.Argv != nil {
+		t.Errorf("Argv = %v, want nil when process.json is missing", ev.Argv)
+	}
+}
+
+func TestOnOciExecStartMissingPidfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-exec-start-missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o := newTestOci()
+	if _, err := o.onOciExecStart(&ociBackend{Name: "crio"}, filepath.Join(dir, execPidPrefix+"missing")); err == nil {
+		t.Error("expected an error for a missing pidfile")
+	}
+}
+
+func TestOnOciExecExit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-exec-exit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const sessionID = "abc123"
+	syncPath := filepath.Join(dir, execSyncPrefix+sessionID)
+	if err := ioutil.WriteFile(syncPath, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOci()
+	ev, err := o.onOciExecExit(&ociBackend{Name: "crio"}, syncPath)
+	if err != nil {
+		t.Fatalf("onOciExecExit: %v", err)
+	}
+
+	if ev.State != ociExecExited {
+		t.Errorf("State = %v, want ociExecExited", ev.State)
+	}
+
+	if ev.ID != filepath.Base(dir) {
+		t.Errorf("ID = %q, want %q", ev.ID, filepath.Base(dir))
+	}
+
+	if ev.SessionID != sessionID {
+		t.Errorf("SessionID = %q, want %q", ev.SessionID, sessionID)
+	}
+
+	if ev.ExitCode != 1 {
+		t.Errorf("ExitCode = %d, want 1", ev.ExitCode)
+	}
+}
+
+func TestReadExecArgv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-read-argv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const sessionID = "xyz789"
+	processPath := filepath.Join(dir, "exec-process-"+sessionID+".json")
+	if err := ioutil.WriteFile(processPath, []byte(`{"args":["ls","-la"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	argv, err := readExecArgv(dir, sessionID)
+	if err != nil {
+		t.Fatalf("readExecArgv: %v", err)
+	}
+
+	if len(argv) != 2 || argv[0] != "ls" || argv[1] != "-la" {
+		t.Errorf("argv = %v", argv)
+	}
+
+	if _, err := readExecArgv(dir, "does-not-exist"); err == nil {
+		t.Error("expected error for a missing process.json")
+	}
+}
@@ -0,0 +1,310 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeStateJSON(t *testing.T, dir, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestOnOciStateUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   ociState
+	}{
+		{"created", `"created"`, ociCreated},
+		{"running", `"running"`, ociRunning},
+		{"stopped", `"stopped"`, ociStopped},
+		{"unknown status defaults to creating", `"paused"`, ociCreating},
+	}
+
+	o := newTestOci()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "oci-test-fedcba9876543210")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			statePath := writeStateJSON(t, dir, `{"ociVersion":"1.0.0","id":"c1","status":`+tt.status+`}`)
+
+			ev, err := o.onOciStateUpdate(&ociBackend{Name: "runc"}, statePath)
+			if err != nil {
+				t.Fatalf("onOciStateUpdate: %v", err)
+			}
+
+			if ev.State != tt.want {
+				t.Errorf("State = %v, want %v", ev.State, tt.want)
+			}
+
+			if ev.ID != "c1" {
+				t.Errorf("ID = %q, want %q", ev.ID, "c1")
+			}
+		})
+	}
+}
+
+func TestOnOciStateUpdateFallsBackToDirNameForID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	statePath := writeStateJSON(t, dir, `{"ociVersion":"1.0.0","status":"running"}`)
+
+	o := newTestOci()
+	ev, err := o.onOciStateUpdate(&ociBackend{Name: "runc"}, statePath)
+	if err != nil {
+		t.Fatalf("onOciStateUpdate: %v", err)
+	}
+
+	if ev.ID != filepath.Base(dir) {
+		t.Errorf("ID = %q, want %q", ev.ID, filepath.Base(dir))
+	}
+}
+
+func TestOnOciStateUpdateMergesCachedSpecFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	containerID := filepath.Base(dir)
+	backend := &ociBackend{Name: "crio"}
+
+	configPath := writeConfigJSON(t, dir, `{
+		"annotations": {"io.kubernetes.pod.uid": "abc-123"},
+		"hostname": "my-container"
+	}`)
+	statePath := writeStateJSON(t, dir, `{"ociVersion":"1.0.0","id":"`+containerID+`","status":"running"}`)
+
+	o := newTestOci()
+
+	if _, err := o.onOciConfigUpdate(backend, configPath); err != nil {
+		t.Fatalf("onOciConfigUpdate: %v", err)
+	}
+
+	ev, err := o.onOciStateUpdate(backend, statePath)
+	if err != nil {
+		t.Fatalf("onOciStateUpdate: %v", err)
+	}
+
+	if ev.State != ociRunning {
+		t.Fatalf("State = %v, want ociRunning", ev.State)
+	}
+
+	if ev.Hostname != "my-container" {
+		t.Errorf("Hostname = %q, want %q (merged from config.json)", ev.Hostname, "my-container")
+	}
+
+	if got := ev.Annotations["io.kubernetes.pod.uid"]; got != "abc-123" {
+		t.Errorf("Annotations[pod.uid] = %q, want %q (merged from config.json)", got, "abc-123")
+	}
+}
+
+func TestOnOciStateUpdateWithoutCachedConfigLeavesSpecFieldsEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	statePath := writeStateJSON(t, dir, `{"ociVersion":"1.0.0","status":"running"}`)
+
+	o := newTestOci()
+	ev, err := o.onOciStateUpdate(&ociBackend{Name: "crio"}, statePath)
+	if err != nil {
+		t.Fatalf("onOciStateUpdate: %v", err)
+	}
+
+	if ev.Spec != nil || ev.Hostname != "" || ev.Annotations != nil {
+		t.Errorf("expected no spec fields without a cached config.json, got %+v", ev)
+	}
+}
+
+func TestReadExitCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-readexitcode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		contents string
+		want     int32
+		wantErr  bool
+	}{
+		{"zero", "0\n", 0, false},
+		{"nonzero with trailing whitespace", "137\n", 137, false},
+		{"no trailing newline", "1", 1, false},
+		{"non-numeric", "oops", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readExitCode(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("readExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readExitCode(filepath.Join(dir, "does-not-exist")); err == nil {
+		t.Error("expected error for missing exit file")
+	}
+}
+
+func TestCheckOOMKilled(t *testing.T) {
+	tests := []struct {
+		name        string
+		cgroupsPath string
+		// relative to a cgroupMemoryRoots entry, e.g. "memory.events"
+		file     string
+		contents string
+		want     bool
+	}{
+		{
+			name:        "no cgroups path",
+			cgroupsPath: "",
+			want:        false,
+		},
+		{
+			name:        "cgroup v2 oom_kill counter incremented",
+			cgroupsPath: "/kubepods/burstable/pod1/container1",
+			file:        "memory.events",
+			contents:    "low 0\nhigh 0\nmax 0\noom 0\noom_kill 1\n",
+			want:        true,
+		},
+		{
+			name:        "cgroup v2 oom_kill counter at zero",
+			cgroupsPath: "/kubepods/burstable/pod1/container1",
+			file:        "memory.events",
+			contents:    "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n",
+			want:        false,
+		},
+		{
+			name:        "cgroup v1 oom_control reports a kill",
+			cgroupsPath: "/docker/abc123",
+			file:        "memory.oom_control",
+			contents:    "oom_kill_disable 0\nunder_oom 1\noom_kill 1\n",
+			want:        true,
+		},
+		{
+			name:        "cgroup v1 oom_control with no kill",
+			cgroupsPath: "/docker/abc123",
+			file:        "memory.oom_control",
+			contents:    "oom_kill_disable 0\nunder_oom 0\n",
+			want:        false,
+		},
+		{
+			name:        "no cgroup files present",
+			cgroupsPath: "/docker/does-not-exist",
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "oci-test-cgroup")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+
+			restore := cgroupMemoryRoots
+			cgroupMemoryRoots = []string{root}
+			defer func() { cgroupMemoryRoots = restore }()
+
+			if tt.file != "" {
+				dir := filepath.Join(root, tt.cgroupsPath)
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ioutil.WriteFile(filepath.Join(dir, tt.file), []byte(tt.contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := checkOOMKilled(tt.cgroupsPath); got != tt.want {
+				t.Errorf("checkOOMKilled(%q) = %v, want %v", tt.cgroupsPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPopulateExitInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-test-populate-exit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root, err := ioutil.TempDir("", "oci-test-populate-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	restore := cgroupMemoryRoots
+	cgroupMemoryRoots = []string{root}
+	defer func() { cgroupMemoryRoots = restore }()
+
+	const cgroupsPath = "/docker/abc123"
+	cgroupDir := filepath.Join(root, cgroupsPath)
+	if err := os.MkdirAll(cgroupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(cgroupDir, "memory.oom_control"), []byte("oom_kill 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exitPath := filepath.Join(dir, "exit")
+	if err := ioutil.WriteFile(exitPath, []byte("137\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOci()
+	o.containerCgroups["abc123"] = cgroupsPath
+
+	ev := &ociEvent{}
+	o.populateExitInfo(ev, "abc123", exitPath)
+
+	if ev.ExitCode != 137 {
+		t.Errorf("ExitCode = %d, want 137", ev.ExitCode)
+	}
+
+	if !ev.OOMKilled {
+		t.Error("expected OOMKilled to be true")
+	}
+
+	if ev.ErrorMessage == "" {
+		t.Error("expected ErrorMessage to be set for an OOM kill")
+	}
+}
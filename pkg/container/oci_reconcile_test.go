@@ -0,0 +1,242 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeWatcher is an ociWatcher that records AddWatch/RemoveWatch calls
+// against real paths instead of opening an inotify file descriptor, so
+// reconcileDir/reconcileContainer/resync can be driven against a real
+// temporary filesystem in tests.
+type fakeWatcher struct {
+	mu        sync.Mutex
+	added     []string
+	removed   []string
+	failPaths map[string]bool
+}
+
+func (f *fakeWatcher) AddWatch(path string, mask uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failPaths[path] {
+		return errors.New("fakeWatcher: AddWatch configured to fail")
+	}
+
+	f.added = append(f.added, path)
+	return nil
+}
+
+func (f *fakeWatcher) RemoveWatch(path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.removed = append(f.removed, path)
+	return nil
+}
+
+func (f *fakeWatcher) hasWatch(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range f.added {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestOciForReconcile(w *fakeWatcher) *oci {
+	return &oci{
+		inotify:           w,
+		data:              make(chan interface{}, 64),
+		watched:           make(map[string]*watchedDir),
+		containerBackend:  make(map[string]*ociBackend),
+		containerCgroups:  make(map[string]string),
+		containerSpecInfo: make(map[string]*ociEvent),
+		exitDirs:          make(map[string]*ociBackend),
+		lastKnownState:    make(map[string]ociState),
+		lastExitInfo:      make(map[string]ociExitInfo),
+	}
+}
+
+func drainEvents(o *oci) []*ociEvent {
+	var evs []*ociEvent
+	for {
+		select {
+		case ev := <-o.data:
+			evs = append(evs, ev.(*ociEvent))
+		default:
+			return evs
+		}
+	}
+}
+
+func TestReconcileDirWatchesNamespaceDirAndSkipsNonNamespaceSiblings(t *testing.T) {
+	root := t.TempDir()
+
+	backend := &ociBackend{
+		Name: "crio",
+		Dirs: []ociStateDir{{Path: root, NamespaceDepth: 1, NamespaceName: "overlay-containers"}},
+	}
+
+	containerID := strings.Repeat("a", containerIDLength)
+	containerDir := filepath.Join(root, "overlay-containers", containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfigJSON(t, containerDir, `{"hostname":"h1"}`)
+	writeStateJSON(t, containerDir, `{"ociVersion":"1.0.0","id":"`+containerID+`","status":"running"}`)
+
+	// A layer directory with the same 64-hex shape as a container ID,
+	// sitting under "overlay" rather than "overlay-containers".
+	layerID := strings.Repeat("b", containerIDLength)
+	layerDir := filepath.Join(root, "overlay", layerID)
+	if err := os.MkdirAll(layerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWatcher{}
+	o := newTestOciForReconcile(fw)
+
+	o.reconcileDir(backend, root, 1, "overlay-containers")
+
+	nsDir := filepath.Join(root, "overlay-containers")
+	if !fw.hasWatch(nsDir) {
+		t.Errorf("expected a watch on the namespace dir %s, got %v", nsDir, fw.added)
+	}
+
+	if !fw.hasWatch(containerDir) {
+		t.Errorf("expected a watch on the container dir %s, got %v", containerDir, fw.added)
+	}
+
+	if fw.hasWatch(filepath.Join(root, "overlay")) || fw.hasWatch(layerDir) {
+		t.Errorf("expected overlay/%s to never be watched, got %v", layerID, fw.added)
+	}
+
+	evs := drainEvents(o)
+	if len(evs) != 1 || evs[0].ID != containerID || evs[0].State != ociRunning {
+		t.Fatalf("events = %+v, want one ociRunning event for %s", evs, containerID)
+	}
+}
+
+func TestReconcileContainerSkipsReemittingUnchangedState(t *testing.T) {
+	root := t.TempDir()
+	backend := &ociBackend{Name: "containerd"}
+
+	containerID := strings.Repeat("a", containerIDLength)
+	containerDir := filepath.Join(root, containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfigJSON(t, containerDir, `{"annotations":{"k":"v"}}`)
+	writeStateJSON(t, containerDir, `{"ociVersion":"1.0.0","id":"`+containerID+`","status":"running"}`)
+
+	fw := &fakeWatcher{}
+	o := newTestOciForReconcile(fw)
+
+	o.reconcileContainer(backend, containerDir)
+	o.reconcileContainer(backend, containerDir)
+
+	evs := drainEvents(o)
+	if len(evs) != 1 {
+		t.Fatalf("events = %+v, want exactly one (second reconcile should be deduped)", evs)
+	}
+
+	if evs[0].Annotations["k"] != "v" {
+		t.Errorf("Annotations[k] = %q, want %q (merged from config.json)", evs[0].Annotations["k"], "v")
+	}
+}
+
+func TestReconcileContainerReemitsStoppedOnExitInfoChange(t *testing.T) {
+	root := t.TempDir()
+	backend := &ociBackend{Name: "containerd"}
+
+	containerID := strings.Repeat("a", containerIDLength)
+	containerDir := filepath.Join(root, containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeStateJSON(t, containerDir, `{"ociVersion":"1.0.0","id":"`+containerID+`","status":"stopped"}`)
+
+	exitPath := filepath.Join(containerDir, "exit")
+	if err := os.WriteFile(exitPath, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &fakeWatcher{}
+	o := newTestOciForReconcile(fw)
+
+	o.reconcileContainer(backend, containerDir)
+	o.reconcileContainer(backend, containerDir)
+
+	evs := drainEvents(o)
+	if len(evs) != 1 || evs[0].ExitCode != 1 {
+		t.Fatalf("events = %+v, want exactly one ociStopped event with ExitCode 1", evs)
+	}
+
+	if err := os.WriteFile(exitPath, []byte("137\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o.reconcileContainer(backend, containerDir)
+
+	evs = drainEvents(o)
+	if len(evs) != 1 || evs[0].ExitCode != 137 {
+		t.Fatalf("events = %+v, want a fresh ociStopped event once ExitCode changed", evs)
+	}
+}
+
+func TestResyncEmitsDeletedForContainerGoneFromDisk(t *testing.T) {
+	root := t.TempDir()
+	backend := &ociBackend{
+		Name: "crio",
+		Dirs: []ociStateDir{{Path: root, NamespaceDepth: 1, NamespaceName: "overlay-containers"}},
+	}
+
+	containerID := strings.Repeat("a", containerIDLength)
+	containerDir := filepath.Join(root, "overlay-containers", containerID)
+	if err := os.MkdirAll(containerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeStateJSON(t, containerDir, `{"ociVersion":"1.0.0","id":"`+containerID+`","status":"running"}`)
+
+	fw := &fakeWatcher{}
+	o := newTestOciForReconcile(fw)
+
+	// A container known from before the (simulated) outage, whose
+	// directory no longer exists on disk.
+	goneID := strings.Repeat("c", containerIDLength)
+	goneDir := filepath.Join(root, "overlay-containers", goneID)
+	o.containerBackend[goneDir] = backend
+	o.lastKnownState[goneID] = ociRunning
+
+	// A container known from before and still present, unchanged.
+	o.containerBackend[containerDir] = backend
+	o.lastKnownState[containerID] = ociRunning
+
+	origRuntimes := ociRuntimes
+	ociRuntimes = map[string]*ociBackend{"crio": backend}
+	defer func() { ociRuntimes = origRuntimes }()
+
+	o.resync()
+
+	evs := drainEvents(o)
+	if len(evs) != 1 || evs[0].ID != goneID || evs[0].State != ociDeleted {
+		t.Fatalf("events = %+v, want exactly one ociDeleted event for %s", evs, goneID)
+	}
+
+	if _, ok := o.containerBackend[containerDir]; !ok {
+		t.Errorf("expected %s to still be tracked after resync", containerDir)
+	}
+
+	if _, ok := o.containerBackend[goneDir]; ok {
+		t.Errorf("expected %s to be dropped after resync", goneDir)
+	}
+}
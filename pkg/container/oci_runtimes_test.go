@@ -0,0 +1,142 @@
+package container
+
+import "testing"
+
+func TestBuildOciRuntimesSeedsBuiltins(t *testing.T) {
+	runtimes := buildOciRuntimes("/var/run/docker/libcontainerd", "")
+
+	if len(runtimes) != len(builtinOciBackends) {
+		t.Fatalf("got %d runtimes, want %d", len(runtimes), len(builtinOciBackends))
+	}
+
+	crio, ok := runtimes["crio"]
+	if !ok {
+		t.Fatal("expected builtin crio backend")
+	}
+
+	if len(crio.Dirs) != 2 || crio.Dirs[0].NamespaceDepth != 1 || crio.Dirs[1].NamespaceDepth != 0 {
+		t.Errorf("crio.Dirs = %+v", crio.Dirs)
+	}
+
+	if crio.Dirs[0].NamespaceName != "overlay-containers" {
+		t.Errorf("crio.Dirs[0].NamespaceName = %q, want \"overlay-containers\"", crio.Dirs[0].NamespaceName)
+	}
+
+	podman, ok := runtimes["podman"]
+	if !ok {
+		t.Fatal("expected builtin podman backend")
+	}
+
+	if len(podman.Dirs) != 1 || podman.Dirs[0].NamespaceName != "overlay-containers" {
+		t.Errorf("podman.Dirs = %+v, want NamespaceName \"overlay-containers\"", podman.Dirs)
+	}
+}
+
+func TestBuildOciRuntimesOverridesDockerContainerDir(t *testing.T) {
+	runtimes := buildOciRuntimes("/custom/docker/dir", "")
+
+	docker := runtimes["docker"]
+	if len(docker.Dirs) != 1 || docker.Dirs[0].Path != "/custom/docker/dir" {
+		t.Errorf("docker.Dirs = %+v", docker.Dirs)
+	}
+}
+
+func TestBuildOciRuntimesParsesExtraRuntimes(t *testing.T) {
+	runtimes := buildOciRuntimes("/var/run/docker/libcontainerd",
+		"containerd:/custom/containerd,myruntime:/var/run/myruntime")
+
+	containerd := runtimes["containerd"]
+	if len(containerd.Dirs) != 1 || containerd.Dirs[0].Path != "/custom/containerd" {
+		t.Errorf("containerd.Dirs = %+v, want override applied", containerd.Dirs)
+	}
+
+	if containerd.Dirs[0].NamespaceDepth != 1 {
+		t.Errorf("containerd.Dirs[0].NamespaceDepth = %d, want 1 (preserved from the builtin backend)", containerd.Dirs[0].NamespaceDepth)
+	}
+
+	custom, ok := runtimes["myruntime"]
+	if !ok {
+		t.Fatal("expected myruntime to be added")
+	}
+
+	if len(custom.Dirs) != 1 || custom.Dirs[0].Path != "/var/run/myruntime" || custom.Dirs[0].NamespaceDepth != 0 {
+		t.Errorf("myruntime.Dirs = %+v", custom.Dirs)
+	}
+
+	if custom.Name != "myruntime" {
+		t.Errorf("myruntime.Name = %q", custom.Name)
+	}
+}
+
+func TestBuildOciRuntimesOverridePreservesNamespaceNameAndExitDir(t *testing.T) {
+	runtimes := buildOciRuntimes("/var/run/docker/libcontainerd", "crio:/custom/crio/storage")
+
+	crio := runtimes["crio"]
+	if len(crio.Dirs) != 2 || crio.Dirs[0].Path != "/custom/crio/storage" {
+		t.Fatalf("crio.Dirs = %+v, want override applied to Dirs[0]", crio.Dirs)
+	}
+
+	if crio.Dirs[0].NamespaceName != "overlay-containers" {
+		t.Errorf("crio.Dirs[0].NamespaceName = %q, want \"overlay-containers\" preserved", crio.Dirs[0].NamespaceName)
+	}
+
+	if crio.Dirs[1].Path != "/var/run/crio" {
+		t.Errorf("crio.Dirs[1] = %+v, want untouched builtin entry", crio.Dirs[1])
+	}
+
+	if crio.ExitDir != "/var/run/crio/exits" {
+		t.Errorf("crio.ExitDir = %q, want preserved", crio.ExitDir)
+	}
+}
+
+func TestBuildOciRuntimesIgnoresEmptyEntries(t *testing.T) {
+	runtimes := buildOciRuntimes("/var/run/docker/libcontainerd", ",,")
+
+	if len(runtimes) != len(builtinOciBackends) {
+		t.Errorf("got %d runtimes, want %d (no extras added)", len(runtimes), len(builtinOciBackends))
+	}
+}
+
+func TestIsContainerID(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"a9c113a8f5e2b1c0d4e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8", true},
+		{"overlay", false},
+		{"overlay-containers", false},
+		{"overlay-layers", false},
+		{"mounts", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isContainerID(tt.name); got != tt.want {
+			t.Errorf("isContainerID(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsNamespaceDir(t *testing.T) {
+	tests := []struct {
+		name          string
+		entryName     string
+		namespaceName string
+		want          bool
+	}{
+		{"unrestricted namespace accepts any entry", "k8s.io", "", true},
+		{"unrestricted namespace accepts another entry", "moby", "", true},
+		{"restricted namespace accepts the exact match", "overlay-containers", "overlay-containers", true},
+		{"restricted namespace rejects overlay", "overlay", "overlay-containers", false},
+		{"restricted namespace rejects overlay-layers", "overlay-layers", "overlay-containers", false},
+		{"restricted namespace rejects mounts", "mounts", "overlay-containers", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNamespaceDir(tt.entryName, tt.namespaceName); got != tt.want {
+				t.Errorf("isNamespaceDir(%q, %q) = %v, want %v", tt.entryName, tt.namespaceName, got, tt.want)
+			}
+		})
+	}
+}